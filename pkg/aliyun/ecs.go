@@ -16,7 +16,11 @@ import (
 
 // ECS the interface of ecs operation set
 type ECS interface {
-	AllocateENI(vSwitch string, securityGroup string, instanceID string) (*types.ENI, error)
+	// AllocateENI creates and attaches a new ENI to instanceID. When
+	// secondaryIPCount > 0 the ENI's full complement of secondary IPs is
+	// prewarmed before the ENI is reported ready, saving the IPAM loop
+	// from round-tripping one AssignIPForENI call per pod at startup.
+	AllocateENI(vSwitch string, securityGroup string, instanceID string, secondaryIPCount int) (*types.ENI, error)
 	GetAttachedENIs(instanceID string, containsMainENI bool) ([]*types.ENI, error)
 	GetENIByID(instanceID, eniID string) (*types.ENI, error)
 	GetENIByMac(instanceID, mac string) (*types.ENI, error)
@@ -24,11 +28,83 @@ type ECS interface {
 	GetENIIPs(eniID string) ([]net.IP, error)
 	AssignIPForENI(eniID string) (net.IP, error)
 	UnAssignIPForENI(eniID string, ip net.IP) error
+	// AssignNIPsForENI assigns count secondary IPs to eniID in a single
+	// OpenAPI call and waits until all of them are visible.
+	AssignNIPsForENI(eniID string, count int) ([]net.IP, error)
+	// UnAssignNIPsForENI releases ips from eniID in a single OpenAPI call.
+	UnAssignNIPsForENI(eniID string, ips []net.IP) error
 	GetInstanceMaxENI(instanceID string) (int, error)
 	GetInstanceMaxPrivateIP(intanceID string) (int, error)
 	GetENIMaxIP(instanceID string, eniID string) (int, error)
+	// RefreshInstanceType invalidates the cached instance type/quota info
+	// for instanceID, forcing the next GetInstanceMaxENI/GetENIMaxIP call
+	// to re-describe it. Useful after a resize.
+	RefreshInstanceType(instanceID string)
+	// TagENI attaches tags to an existing ENI, e.g. so it can later be
+	// identified as terway-managed for GCOrphanedENIs.
+	TagENI(eniID string, tags map[string]string) error
+	// GetAttachedENIsByTags returns ENIs in the region matching all of
+	// filter's key/value pairs, regardless of which instance they're
+	// attached to.
+	GetAttachedENIsByTags(filter map[string]string) ([]*types.ENI, error)
+	// GCOrphanedENIs destroys ENIs tagged for this cluster that are no
+	// longer attached to instanceID, cleaning up leaks from a crashed pod
+	// or a terway upgrade.
+	GCOrphanedENIs(instanceID string) error
+	// AllocateTrunkENI creates and attaches a trunk-mode ENI to instanceID.
+	// A trunk ENI carries many VLAN-tagged branch interfaces, each with its
+	// own security group and VSwitch - the way to get past the per-instance
+	// EniQuantity limit that GetInstanceMaxENI reports.
+	AllocateTrunkENI(vSwitch, securityGroup, instanceID string) (*types.ENI, error)
+	// CreateBranchENI creates a branch ENI and attaches it to trunkENIID
+	// under VLAN vid.
+	CreateBranchENI(trunkENIID, vSwitch, securityGroup string, vid int) (*types.ENI, error)
+	// FreeBranchENI detaches and destroys a branch ENI.
+	FreeBranchENI(branchENIID, trunkENIID string) error
+	// AssignPrefixForENI delegates one /28 IPv4 prefix to eniID, waiting
+	// until it is visible before returning. Only used when the daemon is
+	// configured with EnablePrefixDelegation.
+	AssignPrefixForENI(eniID string) (*net.IPNet, error)
+	// UnAssignPrefixForENI releases a /28 prefix previously delegated to
+	// eniID by AssignPrefixForENI.
+	UnAssignPrefixForENI(eniID string, prefix *net.IPNet) error
+	// GetENIPrefixes returns the /28 prefixes currently delegated to eniID.
+	GetENIPrefixes(eniID string) ([]*net.IPNet, error)
+	// GetENIMaxPrefix returns the number of /28 prefixes eniID's instance
+	// type can hold, the prefix-delegation analogue of GetENIMaxIP.
+	GetENIMaxPrefix(instanceID string, eniID string) (int, error)
 }
 
+// Tag keys terway attaches to ENIs it creates, so orphaned ENIs can be
+// safely distinguished from ENIs belonging to other workloads in the VPC.
+const (
+	clusterIDTagKey  = "terway.io/cluster-id"
+	instanceIDTagKey = "terway.io/instance-id"
+	createdAtTagKey  = "terway.io/created-at"
+	// trunkIDTagKey is set by CreateBranchENI on every branch ENI so
+	// GetAttachedENIs can find the branches of a trunk ENI via
+	// GetAttachedENIsByTags instead of relying on IMDS, which doesn't know
+	// about the trunk/branch relationship.
+	trunkIDTagKey = "terway.io/trunk-id"
+)
+
+// ErrAllSecondaryIPsNotFound is returned by AssignNIPsForENI when the
+// backoff exhausts (or the poll fails) without the OpenAPI getter reporting
+// all of the requested secondary IPs as attached.
+var ErrAllSecondaryIPsNotFound = errors.New("not all secondary ips were found attached after assignment")
+
+// ErrPrefixNotFound is returned by AssignPrefixForENI when the backoff
+// exhausts without the OpenAPI getter reporting the new prefix as attached.
+var ErrPrefixNotFound = errors.New("delegated prefix was not found attached after assignment")
+
+// ErrPrefixDelegationDisabled is returned by the prefix delegation methods
+// when the daemon wasn't configured with EnablePrefixDelegation.
+var ErrPrefixDelegationDisabled = errors.New("prefix delegation is not enabled for this daemon")
+
+// ipv4PrefixLen is the CIDR size Alibaba Cloud delegates per
+// AssignPrivateIpAddresses Ipv4Prefix call.
+const ipv4PrefixLen = 28
+
 type ecsImpl struct {
 	privateIPMutex sync.RWMutex
 	clientSet      *ClientMgr
@@ -36,10 +112,45 @@ type ecsImpl struct {
 	// avoid conflict on ecs
 	openapiInfoGetter ENIInfoGetter
 	region            common.Region
+	// clusterID identifies this cluster in the terway.io/cluster-id tag
+	// attached to ENIs this daemon creates.
+	clusterID string
+
+	// instanceTypeMutex protects instanceTypeCache. An instance's type and
+	// its family's ENI/IP quotas are immutable for the instance's lifetime,
+	// so they're cached to avoid re-describing on every list/sync.
+	instanceTypeMutex sync.RWMutex
+	instanceTypeCache map[string]*instanceTypeInfo
+
+	// enableTrunkENI gates AllocateTrunkENI/CreateBranchENI/FreeBranchENI
+	// behind a daemon config flag, so existing deployments that don't
+	// request trunk mode see no behavior change.
+	enableTrunkENI bool
+
+	// enablePrefixDelegation gates AssignPrefixForENI/UnAssignPrefixForENI/
+	// GetENIPrefixes; when unset they return ErrPrefixDelegationDisabled.
+	// Handing out pod IPs from a delegated /28 is not done here or in the
+	// pool package: AssignIPForENI still assigns one secondary IP per call.
+	enablePrefixDelegation bool
+}
+
+// instanceTypeInfo is the subset of DescribeInstanceAttribute/
+// DescribeInstanceTypesNew results that GetInstanceMaxENI and GetENIMaxIP
+// need, cached per instanceID.
+type instanceTypeInfo struct {
+	instanceType                string
+	instanceTypeFamily          string
+	eniQuantity                 int
+	eniPrivateIpAddressQuantity int
+	eniIPv4PrefixQuantity       int
 }
 
-// NewECS return new ECS implement object
-func NewECS(ak, sk string, region common.Region) (ECS, error) {
+// NewECS return new ECS implement object. enableTrunkENI turns on
+// AllocateTrunkENI/CreateBranchENI/FreeBranchENI support; it should stay
+// false unless the daemon config explicitly opts into trunk ENI mode.
+// enablePrefixDelegation turns on AssignPrefixForENI/UnAssignPrefixForENI
+// support for the EnablePrefixDelegation daemon config knob.
+func NewECS(ak, sk string, region common.Region, clusterID string, enableTrunkENI bool, enablePrefixDelegation bool) (ECS, error) {
 	clientSet, err := NewClientMgr(ak, sk)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error get clientset")
@@ -59,16 +170,24 @@ func NewECS(ak, sk string, region common.Region) (ECS, error) {
 	}
 
 	return &ecsImpl{
-		privateIPMutex:    sync.RWMutex{},
-		clientSet:         clientSet,
-		eniInfoGetter:     &eniMetadata{},
-		openapiInfoGetter: &openapiENIInfoGetter,
-		region:            region,
+		privateIPMutex:         sync.RWMutex{},
+		clientSet:              clientSet,
+		eniInfoGetter:          NewIMDSCache(""),
+		openapiInfoGetter:      &openapiENIInfoGetter,
+		region:                 region,
+		clusterID:              clusterID,
+		instanceTypeCache:      make(map[string]*instanceTypeInfo),
+		enableTrunkENI:         enableTrunkENI,
+		enablePrefixDelegation: enablePrefixDelegation,
 	}, nil
 }
 
+// ErrTrunkENIDisabled is returned by AllocateTrunkENI/CreateBranchENI when
+// the daemon wasn't configured with trunk ENI mode enabled.
+var ErrTrunkENIDisabled = errors.New("trunk eni mode is not enabled for this daemon")
+
 // AllocateENI for instance
-func (e *ecsImpl) AllocateENI(vSwitch string, securityGroup string, instanceID string) (*types.ENI, error) {
+func (e *ecsImpl) AllocateENI(vSwitch string, securityGroup string, instanceID string, secondaryIPCount int) (*types.ENI, error) {
 	if vSwitch == "" || len(securityGroup) == 0 || instanceID == "" {
 		return nil, errors.Errorf("invalid eni args for allocate")
 	}
@@ -127,6 +246,25 @@ func (e *ecsImpl) AllocateENI(vSwitch string, securityGroup string, instanceID s
 		return nil, err
 	}
 
+	// Tag only after the ENI is actually attached (eniStatusInUse): tagging
+	// it for this instance any earlier would let a concurrent GCOrphanedENIs
+	// see an instance-id-tagged ENI that IMDS doesn't report as attached yet
+	// and destroy it out from under this in-flight allocation.
+	err = e.TagENI(createNetworkInterfaceResponse.NetworkInterfaceId, map[string]string{
+		clusterIDTagKey:  e.clusterID,
+		instanceIDTagKey: instanceID,
+		createdAtTagKey:  time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error tag eni %s", createNetworkInterfaceResponse.NetworkInterfaceId)
+	}
+
+	if secondaryIPCount > 0 {
+		if _, err = e.AssignNIPsForENI(createNetworkInterfaceResponse.NetworkInterfaceId, secondaryIPCount); err != nil {
+			return nil, err
+		}
+	}
+
 	describeNetworkInterfacesArgs := &ecs.DescribeNetworkInterfacesArgs{
 		RegionId:           createNetworkInterfaceArgs.RegionId,
 		NetworkInterfaceId: []string{createNetworkInterfaceResponse.NetworkInterfaceId},
@@ -259,13 +397,128 @@ func (e *ecsImpl) GetAttachedENIs(instanceID string, containsMainENI bool) ([]*t
 			return nil, errors.Wrapf(err, "error get eni max ip")
 		}
 	}
+
+	if e.enableTrunkENI {
+		for _, trunkENI := range enis {
+			branches, err := e.getBranchENIs(trunkENI.ID)
+			if err != nil {
+				logrus.Warnf("error list branch enis for trunk %s: %v", trunkENI.ID, err)
+				continue
+			}
+			enis = append(enis, branches...)
+		}
+	}
 	return enis, nil
 }
 
+// getBranchENIs returns the branch ENIs CreateBranchENI attached to
+// trunkENIID, found via the trunkIDTagKey tag rather than IMDS, which has
+// no notion of the trunk/branch relationship.
+func (e *ecsImpl) getBranchENIs(trunkENIID string) ([]*types.ENI, error) {
+	branches, err := e.GetAttachedENIsByTags(map[string]string{trunkIDTagKey: trunkENIID})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error list branch enis for trunk %s", trunkENIID)
+	}
+	for _, branch := range branches {
+		branch.TrunkID = trunkENIID
+	}
+	return branches, nil
+}
+
 func (e *ecsImpl) FreeENI(eniID, instanceID string) error {
 	return e.destroyInterface(eniID, instanceID, true)
 }
 
+// TagENI attaches tags to eniID via a single TagResources call.
+func (e *ecsImpl) TagENI(eniID string, tags map[string]string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	resourceTags := make([]ecs.TagResourcesTag, 0, len(tags))
+	for k, v := range tags {
+		resourceTags = append(resourceTags, ecs.TagResourcesTag{Key: k, Value: v})
+	}
+
+	start := time.Now()
+	_, err := e.clientSet.ecs.TagResources(&ecs.TagResourcesArgs{
+		RegionId:     e.region,
+		ResourceType: ecs.TagResourceEni,
+		ResourceId:   []string{eniID},
+		Tag:          resourceTags,
+	})
+	metric.OpenAPILatency.WithLabelValues("TagResources", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
+	return errors.Wrapf(err, "error tag eni %s", eniID)
+}
+
+// GetAttachedENIsByTags returns the ENIs in the region matching all of
+// filter's key/value pairs, regardless of which instance they're attached
+// to - used by GCOrphanedENIs to enumerate cluster-owned ENIs.
+func (e *ecsImpl) GetAttachedENIsByTags(filter map[string]string) ([]*types.ENI, error) {
+	tags := make([]ecs.TagResourcesTag, 0, len(filter))
+	for k, v := range filter {
+		tags = append(tags, ecs.TagResourcesTag{Key: k, Value: v})
+	}
+
+	start := time.Now()
+	resp, err := e.clientSet.ecs.DescribeNetworkInterfaces(&ecs.DescribeNetworkInterfacesArgs{
+		RegionId: e.region,
+		Tag:      tags,
+	})
+	metric.OpenAPILatency.WithLabelValues("DescribeNetworkInterfaces", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error list enis by tags %v", filter)
+	}
+
+	enis := make([]*types.ENI, 0, len(resp.NetworkInterfaceSets.NetworkInterfaceSet))
+	for _, eniSet := range resp.NetworkInterfaceSets.NetworkInterfaceSet {
+		enis = append(enis, &types.ENI{
+			ID:  eniSet.NetworkInterfaceId,
+			MAC: eniSet.MacAddress,
+		})
+	}
+	return enis, nil
+}
+
+// GCOrphanedENIs destroys ENIs tagged for this cluster *and* this instance
+// that are no longer attached to instanceID - cleanup for ENIs left behind
+// by a crashed Pod or a terway upgrade, now that TagENI lets us tell them
+// apart from other workloads' ENIs in the same VPC. The instance-id tag
+// must be part of the filter: every node in the cluster shares the same
+// cluster-id tag, so filtering on cluster-id alone would return ENIs
+// attached to *other* nodes and destroy them out from under those nodes.
+func (e *ecsImpl) GCOrphanedENIs(instanceID string) error {
+	tagged, err := e.GetAttachedENIsByTags(map[string]string{
+		clusterIDTagKey:  e.clusterID,
+		instanceIDTagKey: instanceID,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "error list tagged enis for gc")
+	}
+
+	attached, err := e.GetAttachedENIs(instanceID, true)
+	if err != nil {
+		return errors.Wrapf(err, "error list attached enis for gc")
+	}
+	attachedIDs := make(map[string]bool, len(attached))
+	for _, eni := range attached {
+		attachedIDs[eni.ID] = true
+	}
+
+	var lastErr error
+	for _, eni := range tagged {
+		if attachedIDs[eni.ID] {
+			continue
+		}
+		logrus.Infof("gc orphaned eni %s not attached to instance %s", eni.ID, instanceID)
+		if err := e.destroyInterface(eni.ID, instanceID, true); err != nil {
+			logrus.Warnf("error destroying orphaned eni %s: %v", eni.ID, err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
 func (e *ecsImpl) GetENIIPs(eniID string) ([]net.IP, error) {
 	e.privateIPMutex.RLock()
 	defer e.privateIPMutex.RUnlock()
@@ -393,70 +646,284 @@ func (e *ecsImpl) UnAssignIPForENI(eniID string, ip net.IP) error {
 	return errors.Wrapf(err, "error unassign eni private address for %s", eniID)
 }
 
-func (e *ecsImpl) GetInstanceMaxENI(instanceID string) (int, error) {
-	eniCap := 0
-	err := wait.ExponentialBackoff(
+// AssignNIPsForENI assigns count secondary IPs to eniID in a single
+// AssignPrivateIpAddresses call, polling openapiInfoGetter until all of
+// them are visible. This trades N round-trips (one per IP) for one,
+// cutting ENI warm-up from O(N) OpenAPI calls to O(1).
+func (e *ecsImpl) AssignNIPsForENI(eniID string, count int) ([]net.IP, error) {
+	e.privateIPMutex.Lock()
+	defer e.privateIPMutex.Unlock()
+
+	addressesBefore, err := e.openapiInfoGetter.GetENIPrivateAddresses(eniID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error get before address for eniID: %v", eniID)
+	}
+
+	assignPrivateIPAddressesArgs := &ecs.AssignPrivateIpAddressesArgs{
+		RegionId:                       e.region,
+		NetworkInterfaceId:             eniID,
+		SecondaryPrivateIpAddressCount: count,
+	}
+
+	start := time.Now()
+	_, err = e.clientSet.ecs.AssignPrivateIpAddresses(assignPrivateIPAddressesArgs)
+	metric.OpenAPILatency.WithLabelValues("AssignPrivateIpAddresses", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error assign %d addresses for eniID: %v", count, eniID)
+	}
+
+	start = time.Now()
+	var addressesAfter []net.IP
+	err = wait.ExponentialBackoff(
 		wait.Backoff{
 			Duration: time.Second,
 			Factor:   2,
 			Jitter:   0,
 			Steps:    5,
-		}, func() (done bool, err error) {
-			start := time.Now()
-			insType, err := e.clientSet.ecs.DescribeInstanceAttribute(instanceID)
-			metric.OpenAPILatency.WithLabelValues("DescribeInstanceAttribute", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
+		},
+		func() (done bool, err error) {
+			addressesAfter, err = e.openapiInfoGetter.GetENIPrivateAddresses(eniID)
 			if err != nil {
-				logrus.Warnf("error get instance info: %s: %v， retry...", instanceID, err)
-				return false, nil
+				return false, errors.Wrapf(err, "error get after eni private address for %s", eniID)
 			}
 
-			start = time.Now()
-			instanceTypeItems, err := e.clientSet.ecs.DescribeInstanceTypesNew(&ecs.DescribeInstanceTypesArgs{
-				InstanceTypeFamily: insType.InstanceTypeFamily,
-			})
-			metric.OpenAPILatency.WithLabelValues("DescribeInstanceTypesNew", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
-
-			if err != nil {
-				logrus.Warnf("error get instance types info: %v， retry...", err)
+			if len(addressesAfter)-len(addressesBefore) != count {
 				return false, nil
 			}
+			return true, nil
+		},
+	)
+	metric.OpenAPILatency.WithLabelValues("AssignPrivateIpAddressesAsync", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
 
-			for _, instanceTypeSpec := range instanceTypeItems {
-				if instanceTypeSpec.InstanceTypeId == insType.InstanceType {
-					eniCap = instanceTypeSpec.EniQuantity
-					break
-				}
+	if err != nil {
+		return nil, errors.Wrapf(ErrAllSecondaryIPsNotFound, "error allocate %d eni private addresses for %s: %v", count, eniID, err)
+	}
+
+	mb := map[string]bool{}
+	for _, beforeIP := range addressesBefore {
+		mb[beforeIP.String()] = true
+	}
+	var newIPs []net.IP
+	for _, afterIP := range addressesAfter {
+		if _, ok := mb[afterIP.String()]; !ok {
+			newIPs = append(newIPs, afterIP)
+		}
+	}
+	return newIPs, nil
+}
+
+// UnAssignNIPsForENI releases ips from eniID in a single
+// UnassignPrivateIpAddresses call.
+func (e *ecsImpl) UnAssignNIPsForENI(eniID string, ips []net.IP) error {
+	if len(ips) == 0 {
+		return nil
+	}
+
+	e.privateIPMutex.Lock()
+	defer e.privateIPMutex.Unlock()
+
+	addressesBefore, err := e.openapiInfoGetter.GetENIPrivateAddresses(eniID)
+	if err != nil {
+		return errors.Wrapf(err, "error get before address for eniID: %v", eniID)
+	}
+
+	ipStrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		ipStrs = append(ipStrs, ip.String())
+	}
+
+	unAssignPrivateIPAddressesArgs := &ecs.UnassignPrivateIpAddressesArgs{
+		RegionId:           e.region,
+		NetworkInterfaceId: eniID,
+		PrivateIpAddress:   ipStrs,
+	}
+
+	start := time.Now()
+	_, err = e.clientSet.ecs.UnassignPrivateIpAddresses(unAssignPrivateIPAddressesArgs)
+	metric.OpenAPILatency.WithLabelValues("UnassignPrivateIpAddresses", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
+	if err != nil {
+		return errors.Wrapf(err, "error unassign %d addresses for eniID: %v", len(ips), eniID)
+	}
+
+	start = time.Now()
+	var addressesAfter []net.IP
+	err = wait.ExponentialBackoff(
+		wait.Backoff{
+			Duration: time.Second,
+			Factor:   2,
+			Jitter:   0,
+			Steps:    5,
+		},
+		func() (done bool, err error) {
+			addressesAfter, err = e.openapiInfoGetter.GetENIPrivateAddresses(eniID)
+			if err != nil {
+				return false, errors.Wrapf(err, "error get after eni private address for %s", eniID)
 			}
 
-			if eniCap == 0 {
-				logrus.Warnf("error get instance type info: %v", insType.InstanceType)
-				return false, errors.Errorf("error get instance type info: %v", insType.InstanceType)
+			if len(addressesBefore)-len(addressesAfter) != len(ips) {
+				return false, nil
 			}
 			return true, nil
-		})
+		},
+	)
+	metric.OpenAPILatency.WithLabelValues("UnassignPrivateIpAddressesAsync", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
+	return errors.Wrapf(err, "error unassign %d eni private addresses for %s", len(ips), eniID)
+}
 
-	return eniCap, errors.Wrapf(err, "error get instance max eni: %v", instanceID)
+// GetENIPrefixes returns the /28 IPv4 prefixes currently delegated to eniID.
+func (e *ecsImpl) GetENIPrefixes(eniID string) ([]*net.IPNet, error) {
+	if !e.enablePrefixDelegation {
+		return nil, ErrPrefixDelegationDisabled
+	}
+
+	start := time.Now()
+	resp, err := e.clientSet.ecs.DescribeNetworkInterfaces(&ecs.DescribeNetworkInterfacesArgs{
+		RegionId:           e.region,
+		NetworkInterfaceId: []string{eniID},
+	})
+	metric.OpenAPILatency.WithLabelValues("DescribeNetworkInterfaces", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error describe eni %s for prefixes", eniID)
+	}
+	if len(resp.NetworkInterfaceSets.NetworkInterfaceSet) != 1 {
+		return nil, errors.Errorf("eni %s not found", eniID)
+	}
+
+	var prefixes []*net.IPNet
+	for _, p := range resp.NetworkInterfaceSets.NetworkInterfaceSet[0].Ipv4PrefixSets.Ipv4PrefixSet {
+		_, prefix, err := net.ParseCIDR(p.Ipv4Prefix)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parse delegated prefix %s on eni %s", p.Ipv4Prefix, eniID)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
 }
 
-func (e *ecsImpl) GetInstanceMaxPrivateIP(instanceID string) (int, error) {
-	maxEni, err := e.GetInstanceMaxENI(instanceID)
+// AssignPrefixForENI delegates one /28 IPv4 prefix to eniID in a single
+// AssignPrivateIpAddresses(Ipv4Prefix) call, polling GetENIPrefixes until
+// the new prefix is visible before returning it - the same
+// wait-until-visible shape AssignIPForENI uses for plain secondary IPs.
+func (e *ecsImpl) AssignPrefixForENI(eniID string) (*net.IPNet, error) {
+	if !e.enablePrefixDelegation {
+		return nil, ErrPrefixDelegationDisabled
+	}
+
+	e.privateIPMutex.Lock()
+	defer e.privateIPMutex.Unlock()
+
+	prefixesBefore, err := e.GetENIPrefixes(eniID)
 	if err != nil {
-		return 0, errors.Wrapf(err, "error get instance max eni: %v", instanceID)
+		return nil, errors.Wrapf(err, "error get before prefixes for eniID: %v", eniID)
 	}
-	maxIP, err := e.GetENIMaxIP(instanceID, "")
+
+	start := time.Now()
+	_, err = e.clientSet.ecs.AssignPrivateIpAddresses(&ecs.AssignPrivateIpAddressesArgs{
+		RegionId:           e.region,
+		NetworkInterfaceId: eniID,
+		Ipv4PrefixCount:    1,
+	})
+	metric.OpenAPILatency.WithLabelValues("AssignPrivateIpAddresses", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
 	if err != nil {
-		return 0, errors.Wrapf(err, "error get eni max ip: %v", instanceID)
+		return nil, errors.Wrapf(err, "error assign prefix for eniID: %v", eniID)
 	}
-	maxIPForInstance := (maxEni - 1) * maxIP
-	if maxIPForInstance <= 0 {
-		return 0, errors.Errorf("instance not support multi ip address: %v ", instanceID)
+
+	before := map[string]bool{}
+	for _, p := range prefixesBefore {
+		before[p.String()] = true
 	}
-	return maxIPForInstance, nil
+
+	start = time.Now()
+	var newPrefix *net.IPNet
+	err = wait.ExponentialBackoff(
+		wait.Backoff{
+			Duration: time.Second,
+			Factor:   2,
+			Jitter:   0,
+			Steps:    5,
+		},
+		func() (done bool, err error) {
+			prefixesAfter, err := e.GetENIPrefixes(eniID)
+			if err != nil {
+				return false, errors.Wrapf(err, "error get after prefixes for eniID: %v", eniID)
+			}
+			for _, p := range prefixesAfter {
+				if !before[p.String()] {
+					newPrefix = p
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+	)
+	metric.OpenAPILatency.WithLabelValues("AssignPrivateIpAddressesAsync", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
+	if err != nil {
+		return nil, errors.Wrapf(ErrPrefixNotFound, "error delegate prefix for %s: %v", eniID, err)
+	}
+	return newPrefix, nil
 }
 
-func (e *ecsImpl) GetENIMaxIP(instanceID string, eniID string) (int, error) {
-	// fixme: the eniid must bind on specified instanceID
-	eniIPCap := 0
+// UnAssignPrefixForENI releases prefix from eniID and waits until
+// GetENIPrefixes no longer reports it.
+func (e *ecsImpl) UnAssignPrefixForENI(eniID string, prefix *net.IPNet) error {
+	if !e.enablePrefixDelegation {
+		return ErrPrefixDelegationDisabled
+	}
+
+	e.privateIPMutex.Lock()
+	defer e.privateIPMutex.Unlock()
+
+	start := time.Now()
+	_, err := e.clientSet.ecs.UnassignPrivateIpAddresses(&ecs.UnassignPrivateIpAddressesArgs{
+		RegionId:           e.region,
+		NetworkInterfaceId: eniID,
+		Ipv4Prefix:         []string{prefix.String()},
+	})
+	metric.OpenAPILatency.WithLabelValues("UnassignPrivateIpAddresses", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
+	if err != nil {
+		return errors.Wrapf(err, "error unassign prefix %s for eniID: %v", prefix, eniID)
+	}
+
+	start = time.Now()
+	err = wait.ExponentialBackoff(
+		wait.Backoff{
+			Duration: time.Second,
+			Factor:   2,
+			Jitter:   0,
+			Steps:    5,
+		},
+		func() (done bool, err error) {
+			prefixesAfter, err := e.GetENIPrefixes(eniID)
+			if err != nil {
+				return false, errors.Wrapf(err, "error get after prefixes for eniID: %v", eniID)
+			}
+			for _, p := range prefixesAfter {
+				if p.String() == prefix.String() {
+					return false, nil
+				}
+			}
+			return true, nil
+		},
+	)
+	metric.OpenAPILatency.WithLabelValues("UnassignPrivateIpAddressesAsync", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
+	return errors.Wrapf(err, "error unassign prefix %s for eniID: %s", prefix, eniID)
+}
+
+// describeInstanceType returns the cached instance type and ENI/IP quotas
+// for instanceID, describing it via OpenAPI at most once for the lifetime
+// of the process (or until RefreshInstanceType invalidates the entry).
+// GetInstanceMaxENI and GetENIMaxIP both call through here so a node with
+// many ENIs no longer pays one DescribeInstanceAttribute/
+// DescribeInstanceTypesNew round-trip per ENI on every list.
+func (e *ecsImpl) describeInstanceType(instanceID string) (*instanceTypeInfo, error) {
+	e.instanceTypeMutex.RLock()
+	cached, ok := e.instanceTypeCache[instanceID]
+	e.instanceTypeMutex.RUnlock()
+	if ok {
+		return cached, nil
+	}
+
+	var info instanceTypeInfo
 	err := wait.ExponentialBackoff(
 		wait.Backoff{
 			Duration: time.Second,
@@ -468,6 +935,7 @@ func (e *ecsImpl) GetENIMaxIP(instanceID string, eniID string) (int, error) {
 			insType, err := e.clientSet.ecs.DescribeInstanceAttribute(instanceID)
 			metric.OpenAPILatency.WithLabelValues("DescribeInstanceAttribute", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
 			if err != nil {
+				logrus.Warnf("error get instance info: %s: %v， retry...", instanceID, err)
 				return false, nil
 			}
 
@@ -478,25 +946,90 @@ func (e *ecsImpl) GetENIMaxIP(instanceID string, eniID string) (int, error) {
 			metric.OpenAPILatency.WithLabelValues("DescribeInstanceTypesNew", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
 
 			if err != nil {
-				logrus.Warnf("error get instance info: %v， retry...", err)
+				logrus.Warnf("error get instance types info: %v， retry...", err)
 				return false, nil
 			}
 
 			for _, instanceTypeSpec := range instanceTypeItems {
 				if instanceTypeSpec.InstanceTypeId == insType.InstanceType {
-					eniIPCap = instanceTypeSpec.EniPrivateIpAddressQuantity
+					info = instanceTypeInfo{
+						instanceType:                insType.InstanceType,
+						instanceTypeFamily:          insType.InstanceTypeFamily,
+						eniQuantity:                 instanceTypeSpec.EniQuantity,
+						eniPrivateIpAddressQuantity: instanceTypeSpec.EniPrivateIpAddressQuantity,
+						eniIPv4PrefixQuantity:       instanceTypeSpec.EniIpv4PrefixQuantity,
+					}
 					break
 				}
 			}
 
-			if eniIPCap == 0 {
+			if info.eniQuantity == 0 {
 				logrus.Warnf("error get instance type info: %v", insType.InstanceType)
 				return false, errors.Errorf("error get instance type info: %v", insType.InstanceType)
 			}
 			return true, nil
 		})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error describe instance type: %v", instanceID)
+	}
 
-	return eniIPCap, errors.Wrapf(err, "error get instance max eni ip: %v", instanceID)
+	e.instanceTypeMutex.Lock()
+	e.instanceTypeCache[instanceID] = &info
+	e.instanceTypeMutex.Unlock()
+
+	return &info, nil
+}
+
+// RefreshInstanceType invalidates the cached instance type/quota info for
+// instanceID, e.g. after a resize changes its instance type.
+func (e *ecsImpl) RefreshInstanceType(instanceID string) {
+	e.instanceTypeMutex.Lock()
+	defer e.instanceTypeMutex.Unlock()
+	delete(e.instanceTypeCache, instanceID)
+}
+
+func (e *ecsImpl) GetInstanceMaxENI(instanceID string) (int, error) {
+	info, err := e.describeInstanceType(instanceID)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error get instance max eni: %v", instanceID)
+	}
+	return info.eniQuantity, nil
+}
+
+func (e *ecsImpl) GetInstanceMaxPrivateIP(instanceID string) (int, error) {
+	maxEni, err := e.GetInstanceMaxENI(instanceID)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error get instance max eni: %v", instanceID)
+	}
+	maxIP, err := e.GetENIMaxIP(instanceID, "")
+	if err != nil {
+		return 0, errors.Wrapf(err, "error get eni max ip: %v", instanceID)
+	}
+	maxIPForInstance := (maxEni - 1) * maxIP
+	if maxIPForInstance <= 0 {
+		return 0, errors.Errorf("instance not support multi ip address: %v ", instanceID)
+	}
+	return maxIPForInstance, nil
+}
+
+func (e *ecsImpl) GetENIMaxIP(instanceID string, eniID string) (int, error) {
+	// fixme: the eniid must bind on specified instanceID
+	info, err := e.describeInstanceType(instanceID)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error get instance max eni ip: %v", instanceID)
+	}
+	return info.eniPrivateIpAddressQuantity, nil
+}
+
+// GetENIMaxPrefix returns the number of /28 IPv4 prefixes eniID's instance
+// type can hold, the prefix-delegation analogue of GetENIMaxIP.
+func (e *ecsImpl) GetENIMaxPrefix(instanceID string, eniID string) (int, error) {
+	// fixme: the eniid must bind on specified instanceID
+	info, err := e.describeInstanceType(instanceID)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error get instance max eni prefix: %v", instanceID)
+	}
+	return info.eniIPv4PrefixQuantity, nil
 }
 
 func (e *ecsImpl) GetENIByID(instanceID, eniID string) (*types.ENI, error) {
@@ -524,3 +1057,171 @@ func (e *ecsImpl) GetENIByMac(instanceID, mac string) (*types.ENI, error) {
 	}
 	return eni, nil
 }
+
+// AllocateTrunkENI creates a trunk-mode ENI and attaches it to instanceID.
+// It follows the same create/attach/wait/tag sequence as AllocateENI, with
+// InstanceType set to Trunk so later CreateBranchENI calls can attach VLAN
+// sub-interfaces to it instead of consuming a whole ENI slot each.
+func (e *ecsImpl) AllocateTrunkENI(vSwitch, securityGroup, instanceID string) (*types.ENI, error) {
+	if !e.enableTrunkENI {
+		return nil, ErrTrunkENIDisabled
+	}
+	if vSwitch == "" || securityGroup == "" || instanceID == "" {
+		return nil, errors.Errorf("invalid eni args for allocate trunk eni")
+	}
+
+	var (
+		start = time.Now()
+		err   error
+	)
+	createNetworkInterfaceArgs := &ecs.CreateNetworkInterfaceArgs{
+		RegionId:             e.region,
+		VSwitchId:            vSwitch,
+		SecurityGroupId:      securityGroup,
+		NetworkInterfaceName: generateEniName(),
+		Description:          eniDescription,
+		InstanceType:         ecs.NetworkInterfaceInstanceTypeTrunk,
+	}
+	createNetworkInterfaceResponse, err := e.clientSet.ecs.CreateNetworkInterface(createNetworkInterfaceArgs)
+	metric.OpenAPILatency.WithLabelValues("CreateNetworkInterface", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			e.destroyInterface(createNetworkInterfaceResponse.NetworkInterfaceId, instanceID, true)
+		}
+	}()
+
+	start = time.Now()
+	err = e.clientSet.ecs.WaitForNetworkInterface(createNetworkInterfaceArgs.RegionId,
+		createNetworkInterfaceResponse.NetworkInterfaceId, eniStatusAvailable, eniCreateTimeout)
+	metric.OpenAPILatency.WithLabelValues("WaitForNetworkInterfaceCreate/"+eniStatusAvailable, fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
+	if err != nil {
+		return nil, err
+	}
+
+	start = time.Now()
+	attachNetworkInterfaceArgs := &ecs.AttachNetworkInterfaceArgs{
+		RegionId:           e.region,
+		NetworkInterfaceId: createNetworkInterfaceResponse.NetworkInterfaceId,
+		InstanceId:         instanceID,
+	}
+	err = e.clientSet.ecs.AttachNetworkInterface(attachNetworkInterfaceArgs)
+	metric.OpenAPILatency.WithLabelValues("AttachNetworkInterface", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
+	if err != nil {
+		return nil, err
+	}
+
+	start = time.Now()
+	err = e.clientSet.ecs.WaitForNetworkInterface(createNetworkInterfaceArgs.RegionId,
+		createNetworkInterfaceResponse.NetworkInterfaceId, eniStatusInUse, eniBindTimeout)
+	metric.OpenAPILatency.WithLabelValues("WaitForNetworkInterfaceBind/"+eniStatusInUse, fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
+	if err != nil {
+		return nil, err
+	}
+
+	err = e.TagENI(createNetworkInterfaceResponse.NetworkInterfaceId, map[string]string{
+		clusterIDTagKey:  e.clusterID,
+		instanceIDTagKey: instanceID,
+		createdAtTagKey:  time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error tag trunk eni %s", createNetworkInterfaceResponse.NetworkInterfaceId)
+	}
+
+	eni := &types.ENI{
+		ID:    createNetworkInterfaceResponse.NetworkInterfaceId,
+		Trunk: true,
+	}
+	return eni, nil
+}
+
+// CreateBranchENI creates a branch ENI and attaches it to trunkENIID under
+// VLAN vid, via AttachNetworkInterface's TrunkNetworkInstanceId/NetworkInterfaceVlanId
+// style of request rather than a plain instance attach.
+func (e *ecsImpl) CreateBranchENI(trunkENIID, vSwitch, securityGroup string, vid int) (*types.ENI, error) {
+	if !e.enableTrunkENI {
+		return nil, ErrTrunkENIDisabled
+	}
+	if trunkENIID == "" || vSwitch == "" || securityGroup == "" {
+		return nil, errors.Errorf("invalid eni args for create branch eni")
+	}
+
+	var (
+		start = time.Now()
+		err   error
+	)
+	createNetworkInterfaceArgs := &ecs.CreateNetworkInterfaceArgs{
+		RegionId:             e.region,
+		VSwitchId:            vSwitch,
+		SecurityGroupId:      securityGroup,
+		NetworkInterfaceName: generateEniName(),
+		Description:          eniDescription,
+	}
+	createNetworkInterfaceResponse, err := e.clientSet.ecs.CreateNetworkInterface(createNetworkInterfaceArgs)
+	metric.OpenAPILatency.WithLabelValues("CreateNetworkInterface", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			e.destroyInterface(createNetworkInterfaceResponse.NetworkInterfaceId, "", true)
+		}
+	}()
+
+	start = time.Now()
+	err = e.clientSet.ecs.WaitForNetworkInterface(createNetworkInterfaceArgs.RegionId,
+		createNetworkInterfaceResponse.NetworkInterfaceId, eniStatusAvailable, eniCreateTimeout)
+	metric.OpenAPILatency.WithLabelValues("WaitForNetworkInterfaceCreate/"+eniStatusAvailable, fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
+	if err != nil {
+		return nil, err
+	}
+
+	err = e.TagENI(createNetworkInterfaceResponse.NetworkInterfaceId, map[string]string{
+		clusterIDTagKey: e.clusterID,
+		createdAtTagKey: time.Now().Format(time.RFC3339),
+		trunkIDTagKey:   trunkENIID,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error tag branch eni %s", createNetworkInterfaceResponse.NetworkInterfaceId)
+	}
+
+	start = time.Now()
+	attachNetworkInterfaceArgs := &ecs.AttachNetworkInterfaceArgs{
+		RegionId:               e.region,
+		NetworkInterfaceId:     createNetworkInterfaceResponse.NetworkInterfaceId,
+		TrunkNetworkInstanceId: trunkENIID,
+		NetworkInterfaceVlanId: vid,
+	}
+	err = e.clientSet.ecs.AttachNetworkInterface(attachNetworkInterfaceArgs)
+	metric.OpenAPILatency.WithLabelValues("AttachNetworkInterface", fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
+	if err != nil {
+		return nil, err
+	}
+
+	start = time.Now()
+	err = e.clientSet.ecs.WaitForNetworkInterface(createNetworkInterfaceArgs.RegionId,
+		createNetworkInterfaceResponse.NetworkInterfaceId, eniStatusInUse, eniBindTimeout)
+	metric.OpenAPILatency.WithLabelValues("WaitForNetworkInterfaceBind/"+eniStatusInUse, fmt.Sprint(err != nil)).Observe(metric.MsSince(start))
+	if err != nil {
+		return nil, err
+	}
+
+	eni := &types.ENI{
+		ID:      createNetworkInterfaceResponse.NetworkInterfaceId,
+		TrunkID: trunkENIID,
+		VID:     vid,
+	}
+	return eni, nil
+}
+
+// FreeBranchENI detaches branchENIID from trunkENIID and destroys it.
+func (e *ecsImpl) FreeBranchENI(branchENIID, trunkENIID string) error {
+	if !e.enableTrunkENI {
+		return ErrTrunkENIDisabled
+	}
+	return e.destroyInterface(branchENIID, "", true)
+}
@@ -0,0 +1,245 @@
+package aliyun
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AliyunContainerService/terway/types"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultIMDSBaseURL = "http://100.100.100.200/latest/meta-data"
+	imdsTimeout        = 5 * time.Second
+
+	// cacheForever marks an endpoint as stable for the life of the
+	// process (primary ENI identity, MAC<->ENI-ID mapping, VSwitch CIDR,
+	// gateway): once fetched, never re-fetched.
+	cacheForever = time.Duration(0)
+	// neverCache marks an endpoint whose value can change underneath us
+	// (the private IP list, mutated by AssignIPForENI/UnAssignIPForENI)
+	// and so must always be fetched fresh.
+	neverCache = time.Duration(-1)
+)
+
+var imdsRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "terway_imds_requests_total",
+	Help: "count of ECS metadata service accesses, split by endpoint and hit/miss",
+}, []string{"endpoint", "result"})
+
+func init() {
+	prometheus.MustRegister(imdsRequests)
+}
+
+// imdsHTTPClient is the minimal surface IMDSCache needs from an HTTP
+// client; satisfied by *http.Client and by a fake server in tests.
+type imdsHTTPClient interface {
+	Get(url string) (*http.Response, error)
+}
+
+type imdsCacheEntry struct {
+	value     string
+	expiresAt time.Time // zero means cacheForever
+}
+
+// IMDSCache is a thin, per-endpoint-TTL cache in front of the ECS metadata
+// service (100.100.100.200), analogous to AWS's split of awsutils/imds.go.
+// It implements ENIInfoGetter so it can be dropped in wherever an
+// eniMetadata was used, with visibility into which endpoints are actually
+// being hit via terway_imds_requests_total.
+type IMDSCache struct {
+	client  imdsHTTPClient
+	baseURL string
+
+	mu    sync.RWMutex
+	cache map[string]imdsCacheEntry
+}
+
+// NewIMDSCache returns an IMDSCache talking to the ECS metadata service at
+// baseURL (defaults to http://100.100.100.200/latest/meta-data). Tests pass
+// a fake server's URL.
+func NewIMDSCache(baseURL string) *IMDSCache {
+	if baseURL == "" {
+		baseURL = defaultIMDSBaseURL
+	}
+	return &IMDSCache{
+		client:  &http.Client{Timeout: imdsTimeout},
+		baseURL: baseURL,
+		cache:   make(map[string]imdsCacheEntry),
+	}
+}
+
+func (c *IMDSCache) get(endpoint string, ttl time.Duration) (string, error) {
+	if ttl != neverCache {
+		c.mu.RLock()
+		entry, ok := c.cache[endpoint]
+		c.mu.RUnlock()
+		if ok && (entry.expiresAt.IsZero() || entry.expiresAt.After(time.Now())) {
+			imdsRequests.WithLabelValues(endpoint, "hit").Inc()
+			return entry.value, nil
+		}
+	}
+	imdsRequests.WithLabelValues(endpoint, "miss").Inc()
+
+	resp, err := c.client.Get(c.baseURL + endpoint)
+	if err != nil {
+		return "", errors.Wrapf(err, "error fetching imds endpoint %s", endpoint)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("imds endpoint %s returned status %d", endpoint, resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Wrapf(err, "error reading imds endpoint %s", endpoint)
+	}
+	value := strings.TrimSpace(string(body))
+
+	if ttl != neverCache {
+		entry := imdsCacheEntry{value: value}
+		if ttl != cacheForever {
+			entry.expiresAt = time.Now().Add(ttl)
+		}
+		c.mu.Lock()
+		c.cache[endpoint] = entry
+		c.mu.Unlock()
+	}
+	return value, nil
+}
+
+// Mac returns the primary ENI's MAC address.
+func (c *IMDSCache) Mac() (string, error) {
+	return c.get("/mac", cacheForever)
+}
+
+func (c *IMDSCache) macs() (string, error) {
+	return c.get("/network/interfaces/macs/", neverCache)
+}
+
+func (c *IMDSCache) macNetworkInterfaceID(mac string) (string, error) {
+	return c.get(fmt.Sprintf("/network/interfaces/macs/%s/network-interface-id", mac), cacheForever)
+}
+
+func (c *IMDSCache) macVSwitchCIDR(mac string) (string, error) {
+	return c.get(fmt.Sprintf("/network/interfaces/macs/%s/vswitch-cidr-block", mac), cacheForever)
+}
+
+func (c *IMDSCache) macGateway(mac string) (string, error) {
+	return c.get(fmt.Sprintf("/network/interfaces/macs/%s/gateway", mac), cacheForever)
+}
+
+// macPrivateIPv4s is never cached: AssignIPForENI/UnAssignIPForENI mutate
+// the set of private IPs behind this endpoint.
+func (c *IMDSCache) macPrivateIPv4s(mac string) (string, error) {
+	return c.get(fmt.Sprintf("/network/interfaces/macs/%s/private-ipv4s", mac), neverCache)
+}
+
+// GetENIConfigByMac implements ENIInfoGetter. It also resolves the VSwitch
+// CIDR and gateway via the IMDS endpoints wrapping them, so callers get the
+// same subnet/gateway info the replaced eniMetadata populated.
+func (c *IMDSCache) GetENIConfigByMac(mac string) (*types.ENI, error) {
+	eniID, err := c.macNetworkInterfaceID(mac)
+	if err != nil {
+		return nil, err
+	}
+	vSwitchCIDR, err := c.macVSwitchCIDR(mac)
+	if err != nil {
+		return nil, err
+	}
+	_, subnet, err := net.ParseCIDR(vSwitchCIDR)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parse vswitch cidr %s for eni %s", vSwitchCIDR, eniID)
+	}
+	gateway, err := c.macGateway(mac)
+	if err != nil {
+		return nil, err
+	}
+	return &types.ENI{
+		ID:          eniID,
+		MAC:         mac,
+		VSwitchCIDR: subnet,
+		GatewayIP:   net.ParseIP(gateway),
+	}, nil
+}
+
+// GetENIConfigByID implements ENIInfoGetter by scanning attached MACs for
+// the one whose network-interface-id matches eniID.
+func (c *IMDSCache) GetENIConfigByID(eniID string) (*types.ENI, error) {
+	enis, err := c.GetAttachedENIs("", true)
+	if err != nil {
+		return nil, err
+	}
+	for _, eni := range enis {
+		if eni.ID == eniID {
+			return eni, nil
+		}
+	}
+	return nil, errors.Errorf("eni %s not found via imds", eniID)
+}
+
+// GetAttachedENIs implements ENIInfoGetter. instanceID is unused - IMDS
+// always describes the calling instance - kept only to satisfy the
+// interface shared with the OpenAPI-backed getter.
+func (c *IMDSCache) GetAttachedENIs(_ string, containsMainENI bool) ([]*types.ENI, error) {
+	raw, err := c.macs()
+	if err != nil {
+		return nil, err
+	}
+	primaryMac, err := c.Mac()
+	if err != nil {
+		return nil, err
+	}
+
+	var enis []*types.ENI
+	for _, mac := range strings.Fields(raw) {
+		mac = strings.TrimSuffix(mac, "/")
+		if mac == "" {
+			continue
+		}
+		if mac == primaryMac && !containsMainENI {
+			continue
+		}
+		eni, err := c.GetENIConfigByMac(mac)
+		if err != nil {
+			return nil, err
+		}
+		enis = append(enis, eni)
+	}
+	return enis, nil
+}
+
+// GetENIPrivateAddresses implements ENIInfoGetter. Always fetched fresh.
+func (c *IMDSCache) GetENIPrivateAddresses(eniID string) ([]net.IP, error) {
+	enis, err := c.GetAttachedENIs("", true)
+	if err != nil {
+		return nil, err
+	}
+	var mac string
+	for _, eni := range enis {
+		if eni.ID == eniID {
+			mac = eni.MAC
+			break
+		}
+	}
+	if mac == "" {
+		return nil, errors.Errorf("eni %s not found via imds", eniID)
+	}
+
+	raw, err := c.macPrivateIPv4s(mac)
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	for _, ipStr := range strings.Fields(raw) {
+		if ip := net.ParseIP(strings.TrimSpace(ipStr)); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
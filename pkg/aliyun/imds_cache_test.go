@@ -0,0 +1,95 @@
+package aliyun
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func fakeIMDSServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mac", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("00:16:3e:00:00:01"))
+	})
+	mux.HandleFunc("/network/interfaces/macs/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/network/interfaces/macs/":
+			_, _ = w.Write([]byte("00:16:3e:00:00:01/\n"))
+		case "/network/interfaces/macs/00:16:3e:00:00:01/network-interface-id":
+			_, _ = w.Write([]byte("eni-fake1"))
+		case "/network/interfaces/macs/00:16:3e:00:00:01/vswitch-cidr-block":
+			_, _ = w.Write([]byte("192.168.0.0/24"))
+		case "/network/interfaces/macs/00:16:3e:00:00:01/gateway":
+			_, _ = w.Write([]byte("192.168.0.1"))
+		case "/network/interfaces/macs/00:16:3e:00:00:01/private-ipv4s":
+			_, _ = w.Write([]byte("192.168.0.10\n192.168.0.11"))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestIMDSCacheCachesStableEndpoints(t *testing.T) {
+	srv := fakeIMDSServer(t)
+	defer srv.Close()
+
+	hits := 0
+	base := &countingClient{inner: http.DefaultClient, onRequest: func() { hits++ }}
+
+	c := NewIMDSCache(srv.URL)
+	c.client = base
+
+	const stableEndpointsPerCall = 3 // network-interface-id, vswitch-cidr-block, gateway
+	for i := 0; i < 3; i++ {
+		eni, err := c.GetENIConfigByMac("00:16:3e:00:00:01")
+		if err != nil {
+			t.Fatalf("GetENIConfigByMac: %v", err)
+		}
+		if eni.ID != "eni-fake1" {
+			t.Fatalf("expected eni-fake1, got %s", eni.ID)
+		}
+	}
+	if hits != stableEndpointsPerCall {
+		t.Fatalf("expected each stable endpoint to be fetched once, got %d requests", hits)
+	}
+}
+
+func TestIMDSCacheNeverCachesPrivateIPs(t *testing.T) {
+	srv := fakeIMDSServer(t)
+	defer srv.Close()
+
+	requests := 0
+	c := NewIMDSCache(srv.URL)
+	c.client = &countingClient{inner: http.DefaultClient, onRequest: func() { requests++ }}
+
+	for i := 0; i < 2; i++ {
+		before := requests
+		ips, err := c.GetENIPrivateAddresses("eni-fake1")
+		if err != nil {
+			t.Fatalf("GetENIPrivateAddresses: %v", err)
+		}
+		if len(ips) != 2 {
+			t.Fatalf("expected 2 ips, got %d", len(ips))
+		}
+		// Once the stable endpoints are cached, a call still fetches the MAC
+		// list and private-ipv4s, neither of which is cached.
+		if i > 0 && requests-before != 2 {
+			t.Fatalf("expected private-ipv4s to be re-fetched, got %d requests", requests-before)
+		}
+	}
+}
+
+// countingClient wraps an imdsHTTPClient to count outbound requests so
+// tests can assert on cache hit/miss behavior without depending on
+// Prometheus counter internals.
+type countingClient struct {
+	inner     imdsHTTPClient
+	onRequest func()
+}
+
+func (c *countingClient) Get(url string) (*http.Response, error) {
+	c.onRequest()
+	return c.inner.Get(url)
+}
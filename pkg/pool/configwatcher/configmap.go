@@ -0,0 +1,104 @@
+// Package configwatcher provides a pool.ConfigWatcher backed by a
+// Kubernetes ConfigMap informer, so operators can roll out a new minIdle/
+// maxIdle/capacity across a cluster without pod-level disruption.
+package configwatcher
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/AliyunContainerService/terway/pkg/pool"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Keys expected in the watched ConfigMap's Data.
+const (
+	KeyMinIdle  = "minIdle"
+	KeyMaxIdle  = "maxIdle"
+	KeyCapacity = "capacity"
+)
+
+// ConfigMapWatcher watches a single ConfigMap and emits a pool.Config each
+// time minIdle/maxIdle/capacity change, mirroring the KV-watch pattern used
+// by service meshes and gateways (etcd/consul Watch with a stop channel).
+type ConfigMapWatcher struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+}
+
+// NewConfigMapWatcher returns a watcher for the ConfigMap namespace/name.
+func NewConfigMapWatcher(client kubernetes.Interface, namespace, name string) *ConfigMapWatcher {
+	return &ConfigMapWatcher{client: client, namespace: namespace, name: name}
+}
+
+// Watch implements pool.ConfigWatcher. The returned channel is never closed -
+// the informer's event handlers keep emit as a live sender for the life of
+// the process, so closing it here would race a handler's send against
+// shutdown. Callers should stop reading once ctx is done instead.
+func (w *ConfigMapWatcher) Watch(ctx context.Context) (<-chan pool.Config, error) {
+	out := make(chan pool.Config, 1)
+
+	factory := informers.NewSharedInformerFactoryWithOptions(w.client, 0,
+		informers.WithNamespace(w.namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", w.name).String()
+		}),
+	)
+	informer := factory.Core().V1().ConfigMaps().Informer()
+
+	emit := func(obj interface{}) {
+		cm, ok := obj.(*corev1.ConfigMap)
+		if !ok {
+			return
+		}
+		cfg, err := parseConfig(cm)
+		if err != nil {
+			return
+		}
+		select {
+		case out <- cfg:
+		case <-ctx.Done():
+		}
+	}
+
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    emit,
+		UpdateFunc: func(_, newObj interface{}) { emit(newObj) },
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error registering configmap handler for %s/%s: %v", w.namespace, w.name, err)
+	}
+
+	stopCh := make(chan struct{})
+	go factory.Start(stopCh)
+
+	go func() {
+		<-ctx.Done()
+		close(stopCh)
+	}()
+
+	return out, nil
+}
+
+func parseConfig(cm *corev1.ConfigMap) (pool.Config, error) {
+	minIdle, err := strconv.Atoi(cm.Data[KeyMinIdle])
+	if err != nil {
+		return pool.Config{}, fmt.Errorf("error parsing %s: %v", KeyMinIdle, err)
+	}
+	maxIdle, err := strconv.Atoi(cm.Data[KeyMaxIdle])
+	if err != nil {
+		return pool.Config{}, fmt.Errorf("error parsing %s: %v", KeyMaxIdle, err)
+	}
+	capacity, err := strconv.Atoi(cm.Data[KeyCapacity])
+	if err != nil {
+		return pool.Config{}, fmt.Errorf("error parsing %s: %v", KeyCapacity, err)
+	}
+	return pool.Config{MinIdle: minIdle, MaxIdle: maxIdle, Capacity: capacity}, nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/AliyunContainerService/terway/types"
@@ -19,6 +20,7 @@ var (
 	ErrNotFound            = errors.New("not found")
 	ErrContextDone         = errors.New("context done")
 	ErrInvalidArguments    = errors.New("invalid arguments")
+	ErrPoolTimeout         = errors.New("timeout waiting for pool resource")
 )
 
 const (
@@ -34,6 +36,40 @@ type ObjectPool interface {
 	Release(resID string) error
 	AcquireAny(ctx context.Context, idempotentKey string) (types.NetworkResource, error)
 	Stat(resID string) error
+	Stats() PoolStats
+	UpdateConfig(minIdle, maxIdle, capacity int) error
+}
+
+// ConfigWatcher watches an external source of pool sizing and pushes
+// updates so operators can tune a running pool (via UpdateConfig) without
+// restarting the terway daemon.
+type ConfigWatcher interface {
+	// Watch returns a channel of Config updates. Implementations are not
+	// required to close it on ctx done - callers should stop reading once
+	// ctx is done rather than relying on channel closure.
+	Watch(ctx context.Context) (<-chan Config, error)
+}
+
+// PoolStats is a point-in-time snapshot of pool telemetry, modeled after
+// go-redis's PoolStats. All counters are monotonically increasing for the
+// lifetime of the pool; Idle/Inuse/Capacity/Backoff reflect current state.
+type PoolStats struct {
+	Idle     int
+	Inuse    int
+	Capacity int
+
+	Hits     uint64
+	Misses   uint64
+	Timeouts uint64
+
+	Creates         uint64
+	CreateFailures  uint64
+	Disposes        uint64
+	DisposeFailures uint64
+	Releases        uint64
+
+	TotalAcquireWait time.Duration
+	CurrentBackoff   time.Duration
 }
 
 // ResourceHolder interface to initialize pool
@@ -58,9 +94,87 @@ type simpleObjectPool struct {
 	capacity   int
 	maxBackoff time.Duration
 	notifyCh   chan interface{}
+	// maxIdleTime disposes an idle item once it has sat unused this long,
+	// even when idle count is under maxIdle. Zero disables the check.
+	maxIdleTime time.Duration
+	// maxLifetime disposes an idle item once it was created this long ago;
+	// inuse items are not reaped. Zero disables the check.
+	maxLifetime time.Duration
+	// createReqCh coalesces concurrent Acquire create requests so the
+	// coordinator goroutine can issue one batched factory.Create call
+	// instead of one per waiter.
+	createReqCh chan *createRequest
+	// poolTimeout bounds how long Acquire sits in waiters before failing
+	// with ErrPoolTimeout. Zero means wait indefinitely (bounded only by ctx).
+	poolTimeout time.Duration
+	// waiters is a FIFO queue of callers blocked in Acquire because the pool
+	// is at capacity with no idle item; ReleaseWithReverse hands a released
+	// resource straight to the oldest waiter instead of putting it idle.
+	waiters []chan *poolItem
 	// concurrency to create resource. tokenCh = capacity - (idle + inuse + dispose)
 	tokenCh     chan struct{}
 	backoffTime time.Duration
+
+	stats poolStatCounters
+
+	stateStore StateStore
+	// pendingInuse holds the inuse records loaded from stateStore at
+	// startup, keyed by resource ID, not yet replayed into p.inuse. AddIdle
+	// consults it so a resource the Initializer re-derives from cloud
+	// metadata and reports via AddIdle, but which was persisted as inuse
+	// before a restart, comes back inuse with its original idempotentKey
+	// instead of idle.
+	pendingInuse map[string]string
+	// pendingIdle holds the reverse deadlines loaded from stateStore at
+	// startup, keyed by resource ID, not yet replayed into p.idle. AddIdle
+	// consults it so a resource re-derived from cloud metadata and reported
+	// via AddIdle comes back with its original reverse reservation instead
+	// of one reset to now.
+	pendingIdle map[string]time.Time
+}
+
+// StateStore persists pool inventory (inuse idempotent keys and idle reverse
+// deadlines) so a restarted terway daemon can recover pool state without
+// re-deriving it from cloud metadata. Implementations must be safe for
+// concurrent use; simpleObjectPool calls most of them while holding p.lock.
+type StateStore interface {
+	// LoadInuse returns the resource IDs and idempotent keys persisted from
+	// a previous run. A listed resource that the Initializer reports via
+	// AddIdle is restored inuse with its key; one it never reports is not
+	// restored.
+	LoadInuse() (map[string]string, error)
+	// PersistInuse records that resID is now inuse with the given
+	// idempotentKey.
+	PersistInuse(resID, idempotentKey string) error
+	// DeleteInuse removes any persisted inuse record for resID.
+	DeleteInuse(resID string) error
+	// PersistIdle records that resID is idle and must not be re-leased
+	// before reverseAt.
+	PersistIdle(resID string, reverseAt time.Time) error
+	// LoadIdle returns the resource IDs and reverse deadlines persisted from
+	// a previous run. A listed resource that the Initializer reports via
+	// AddIdle gets its persisted reverse deadline back; one it never reports
+	// is not restored.
+	LoadIdle() (map[string]time.Time, error)
+	// DeleteIdle removes any persisted idle record for resID.
+	DeleteIdle(resID string) error
+}
+
+// poolStatCounters holds the atomic counters backing Stats(). Kept separate
+// from simpleObjectPool's other fields so instrumentation sites can update
+// them without taking p.lock.
+type poolStatCounters struct {
+	hits     uint64
+	misses   uint64
+	timeouts uint64
+
+	creates         uint64
+	createFailures  uint64
+	disposes        uint64
+	disposeFailures uint64
+	releases        uint64
+
+	totalAcquireWait int64 // time.Duration, nanoseconds
 }
 
 // Config configuration of pool
@@ -70,12 +184,29 @@ type Config struct {
 	MinIdle     int
 	MaxIdle     int
 	Capacity    int
+	// StateStore persists inventory for crash recovery. Optional; when nil
+	// the pool behaves as before and relies solely on Initializer.
+	StateStore StateStore
+	// MaxIdleTime, if set, disposes an idle item once it has sat unused
+	// this long, independent of the maxIdle/capacity-driven reaping that
+	// checkIdle already does.
+	MaxIdleTime time.Duration
+	// MaxLifetime, if set, disposes an idle item once it was created this
+	// long ago, to rotate out resources that may have accumulated
+	// driver-side state drift. Inuse items are not reaped.
+	MaxLifetime time.Duration
+	// PoolTimeout bounds how long Acquire will sit in the waiter queue when
+	// the pool is at capacity, before returning ErrPoolTimeout. Zero waits
+	// indefinitely (bounded only by the caller's ctx).
+	PoolTimeout time.Duration
 }
 
 type poolItem struct {
 	res           types.NetworkResource
 	reverse       time.Time
 	idempotentKey string
+	createdAt     time.Time
+	lastUsedAt    time.Time
 }
 
 func (i *poolItem) lessThan(other *poolItem) bool {
@@ -105,6 +236,25 @@ func NewSimpleObjectPool(cfg Config) (ObjectPool, error) {
 		notifyCh:    make(chan interface{}, 1),
 		tokenCh:     make(chan struct{}, cfg.Capacity),
 		backoffTime: defaultPoolBackoff,
+		stateStore:  cfg.StateStore,
+		maxIdleTime: cfg.MaxIdleTime,
+		maxLifetime: cfg.MaxLifetime,
+		createReqCh: make(chan *createRequest, cfg.Capacity),
+		poolTimeout: cfg.PoolTimeout,
+	}
+
+	if cfg.StateStore != nil {
+		persistedInuse, err := cfg.StateStore.LoadInuse()
+		if err != nil {
+			return nil, err
+		}
+		pool.pendingInuse = persistedInuse
+
+		persistedIdle, err := cfg.StateStore.LoadIdle()
+		if err != nil {
+			return nil, err
+		}
+		pool.pendingIdle = persistedIdle
 	}
 
 	if cfg.Initializer != nil {
@@ -125,6 +275,7 @@ func NewSimpleObjectPool(cfg Config) (ObjectPool, error) {
 		mapKeys(pool.inuse))
 
 	go pool.startCheckIdleTicker()
+	go pool.runCreateCoordinator()
 
 	return pool, nil
 }
@@ -166,15 +317,80 @@ func (p *simpleObjectPool) dispose(res types.NetworkResource) {
 	if err := p.factory.Dispose(res); err != nil {
 		//put it back on dispose fail
 		log.Warnf("failed dispose %s: %v, put it back to idle", res.GetResourceID(), err)
+		atomic.AddUint64(&p.stats.disposeFailures, 1)
 	} else {
-		p.tokenCh <- struct{}{}
+		p.returnToken()
+		atomic.AddUint64(&p.stats.disposes, 1)
 	}
 }
 
+// returnToken gives a token back to tokenCh. It reads the channel pointer
+// under p.lock so it can't race with UpdateConfig swapping in a bigger
+// channel; the send itself happens on that local copy, outside the lock.
+func (p *simpleObjectPool) returnToken() {
+	p.lock.Lock()
+	ch := p.tokenCh
+	p.lock.Unlock()
+	ch <- struct{}{}
+}
+
 func (p *simpleObjectPool) tooManyIdleLocked() bool {
 	return p.idle.Size() > p.maxIdle || (p.idle.Size() > 0 && p.sizeLocked() > p.capacity)
 }
 
+// UpdateConfig resizes the pool without a restart: shrinking capacity
+// drains excess tokens (idle disposal catches up on the next checkIdle
+// tick) and growing it pushes new tokens into tokenCh; any minIdle/maxIdle
+// change triggers an immediate checkIdle+checkInsufficient.
+func (p *simpleObjectPool) UpdateConfig(minIdle, maxIdle, capacity int) error {
+	if minIdle > maxIdle || maxIdle > capacity {
+		return ErrInvalidArguments
+	}
+
+	p.lock.Lock()
+	oldCapacity := p.capacity
+	delta := capacity - oldCapacity
+
+	if cap(p.tokenCh) < capacity {
+		// tokenCh's buffer size is fixed at creation time; growing beyond
+		// it means swapping in a bigger channel, carrying over whatever
+		// tokens are already available.
+		newTokenCh := make(chan struct{}, capacity)
+	drainOld:
+		for {
+			select {
+			case <-p.tokenCh:
+				newTokenCh <- struct{}{}
+			default:
+				break drainOld
+			}
+		}
+		p.tokenCh = newTokenCh
+	}
+
+	p.minIdle = minIdle
+	p.maxIdle = maxIdle
+	p.capacity = capacity
+	p.lock.Unlock()
+
+	switch {
+	case delta > 0:
+		for i := 0; i < delta; i++ {
+			p.returnToken()
+		}
+	case delta < 0:
+		drained := p.claimTokens(-delta)
+		if drained < -delta {
+			log.Warnf("UpdateConfig: drained only %d of %d tokens while shrinking capacity to %d; "+
+				"the remainder will be reclaimed as inuse/idle items are released", drained, -delta, capacity)
+		}
+	}
+
+	log.Infof("pool reconfigured: minIdle %d, maxIdle %d, capacity %d", minIdle, maxIdle, capacity)
+	p.notify()
+	return nil
+}
+
 func (p *simpleObjectPool) needAddition() int {
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -204,7 +420,7 @@ func (p *simpleObjectPool) peekOverfullIdle() *poolItem {
 	return p.idle.Pop()
 }
 
-//found resources that can be disposed, put them into dispose channel
+// found resources that can be disposed, put them into dispose channel
 func (p *simpleObjectPool) checkIdle() {
 	for {
 		item := p.peekOverfullIdle()
@@ -216,15 +432,55 @@ func (p *simpleObjectPool) checkIdle() {
 		log.Infof("try dispose res %+v", res)
 		err := p.factory.Dispose(res)
 		if err == nil {
-			p.tokenCh <- struct{}{}
+			p.returnToken()
 			p.backoffTime = defaultPoolBackoff
+			atomic.AddUint64(&p.stats.disposes, 1)
+			if p.stateStore != nil {
+				if err := p.stateStore.DeleteIdle(res.GetResourceID()); err != nil {
+					log.Warnf("error deleting idle state for %s: %v", res.GetResourceID(), err)
+				}
+			}
 		} else {
 			log.Warnf("error dispose res: %+v", err)
 			p.backoffTime = p.backoffTime * 2
-			p.AddIdle(res)
+			p.addIdleWithTimestamps(res, item.createdAt, item.lastUsedAt)
+			atomic.AddUint64(&p.stats.disposeFailures, 1)
 			time.Sleep(p.backoffTime)
 		}
 	}
+
+	for _, item := range p.expireStaleIdleLocked() {
+		res := item.res
+		log.Infof("try dispose stale idle res %+v (idle %s, age %s)", res,
+			time.Since(item.lastUsedAt), time.Since(item.createdAt))
+		if err := p.factory.Dispose(res); err != nil {
+			log.Warnf("error dispose stale idle res: %+v", err)
+			atomic.AddUint64(&p.stats.disposeFailures, 1)
+			p.addIdleWithTimestamps(res, item.createdAt, item.lastUsedAt)
+			continue
+		}
+		p.returnToken()
+		atomic.AddUint64(&p.stats.disposes, 1)
+		if p.stateStore != nil {
+			if err := p.stateStore.DeleteIdle(res.GetResourceID()); err != nil {
+				log.Warnf("error deleting idle state for %s: %v", res.GetResourceID(), err)
+			}
+		}
+	}
+}
+
+// expireStaleIdleLocked pulls idle items that have exceeded MaxIdleTime or
+// MaxLifetime out of the idle queue, regardless of maxIdle/capacity. Unlike
+// peekOverfullIdle (which only reaps when the pool is over its idle
+// threshold), this is a second pass over the full queue so items can be
+// rotated out purely on age.
+func (p *simpleObjectPool) expireStaleIdleLocked() []*poolItem {
+	if p.maxIdleTime <= 0 && p.maxLifetime <= 0 {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.idle.expireStaleIdleLocked(p.maxIdleTime, p.maxLifetime)
 }
 
 func (p *simpleObjectPool) checkInsufficient() {
@@ -232,16 +488,7 @@ func (p *simpleObjectPool) checkInsufficient() {
 	if addition <= 0 {
 		return
 	}
-	var tokenAcquired int
-	for i := 0; i < addition; i++ {
-		// pending resources
-		select {
-		case <-p.tokenCh:
-			tokenAcquired++
-		default:
-			continue
-		}
-	}
+	tokenAcquired := p.claimTokens(addition)
 	log.Debugf("token acquired count: %v", tokenAcquired)
 	if tokenAcquired <= 0 {
 		return
@@ -249,10 +496,12 @@ func (p *simpleObjectPool) checkInsufficient() {
 	resList, err := p.factory.Create(tokenAcquired)
 	if err != nil {
 		log.Errorf("error add idle network resources: %v", err)
+		atomic.AddUint64(&p.stats.createFailures, 1)
 	}
 	if tokenAcquired == len(resList) {
 		p.backoffTime = defaultPoolBackoff
 	}
+	atomic.AddUint64(&p.stats.creates, uint64(len(resList)))
 	for _, res := range resList {
 		log.Infof("add resource %s to pool idle", res.GetResourceID())
 		p.AddIdle(res)
@@ -260,7 +509,7 @@ func (p *simpleObjectPool) checkInsufficient() {
 	}
 	for i := 0; i < tokenAcquired; i++ {
 		// release token
-		p.tokenCh <- struct{}{}
+		p.returnToken()
 	}
 	if tokenAcquired != 0 {
 		log.Debugf("token acquired left: %d, err: %v", tokenAcquired, err)
@@ -300,50 +549,285 @@ func (p *simpleObjectPool) getOneLocked(resID string) *poolItem {
 }
 
 func (p *simpleObjectPool) Acquire(ctx context.Context, resID, idempotentKey string) (types.NetworkResource, error) {
+	waitStart := time.Now()
+	defer func() {
+		atomic.AddInt64(&p.stats.totalAcquireWait, int64(time.Since(waitStart)))
+	}()
+
 	p.lock.Lock()
 	if resItem, ok := p.inuse[resID]; ok && resItem.idempotentKey == idempotentKey {
 		p.lock.Unlock()
+		atomic.AddUint64(&p.stats.hits, 1)
 		return resItem.res, nil
 	}
 
 	if p.idle.Size() > 0 {
-		res := p.getOneLocked(resID).res
-		p.inuse[res.GetResourceID()] = poolItem{res: res, idempotentKey: idempotentKey}
+		idleItem := p.getOneLocked(resID)
+		res := idleItem.res
+		p.addInuseLocked(res, idempotentKey, idleItem.createdAt)
 		p.lock.Unlock()
 		log.Infof("acquire (expect %s): return idle %s", resID, res.GetResourceID())
+		atomic.AddUint64(&p.stats.hits, 1)
 		p.notify()
 		return res, nil
 	}
 	size := p.sizeLocked()
 	if size >= p.capacity {
+		w := make(chan *poolItem, 1)
+		p.waiters = append(p.waiters, w)
 		p.lock.Unlock()
-		log.Infof("acquire (expect %s), size %d, capacity %d: return err %v", resID, size, p.capacity, ErrNoAvailableResource)
-		return nil, ErrNoAvailableResource
+		log.Infof("acquire (expect %s), size %d, capacity %d: waiting for a release", resID, size, p.capacity)
+		atomic.AddUint64(&p.stats.misses, 1)
+		return p.waitForRelease(ctx, resID, idempotentKey, w)
 	}
 
 	p.lock.Unlock()
+	atomic.AddUint64(&p.stats.misses, 1)
 
+	req := &createRequest{resultCh: make(chan createResult, 1)}
 	select {
-	case <-p.tokenCh:
-		//should we pass ctx into factory.Create?
-		res, err := p.factory.Create(1)
-		if err != nil || len(res) == 0 {
-			p.tokenCh <- struct{}{}
-			return nil, fmt.Errorf("error create from factory: %v", err)
+	case p.createReqCh <- req:
+	case <-ctx.Done():
+		log.Infof("acquire (expect %s): return err %v", resID, ErrContextDone)
+		atomic.AddUint64(&p.stats.timeouts, 1)
+		return nil, ErrContextDone
+	}
+
+	select {
+	case result := <-req.resultCh:
+		if result.err != nil {
+			atomic.AddUint64(&p.stats.createFailures, 1)
+			return nil, result.err
 		}
-		log.Infof("acquire (expect %s): return newly %s", resID, res[0].GetResourceID())
-		p.AddInuse(res[0], idempotentKey)
-		return res[0], nil
+		atomic.AddUint64(&p.stats.creates, 1)
+		log.Infof("acquire (expect %s): return newly %s", resID, result.res.GetResourceID())
+		p.AddInuse(result.res, idempotentKey)
+		return result.res, nil
 	case <-ctx.Done():
 		log.Infof("acquire (expect %s): return err %v", resID, ErrContextDone)
+		atomic.AddUint64(&p.stats.timeouts, 1)
+		// The coordinator may already be acting on this request and will
+		// write into resultCh regardless of whether we're still listening;
+		// without this, a resource it creates (and the token it claimed)
+		// would never be added to the pool and would leak. Finish waiting
+		// for that result in the background and fold it into idle instead.
+		go p.abandonCreateRequest(req)
 		return nil, ErrContextDone
 	}
 }
 
+// abandonCreateRequest waits for the result of a createRequest whose caller
+// has already given up (ctx done), and reclaims whatever the coordinator
+// produced instead of leaking it: a created resource goes idle rather than
+// inuse, since there's no longer a caller to hand it to.
+func (p *simpleObjectPool) abandonCreateRequest(req *createRequest) {
+	result := <-req.resultCh
+	if result.err != nil {
+		return
+	}
+	atomic.AddUint64(&p.stats.creates, 1)
+	log.Infof("acquire abandoned by caller: adding newly created %s to idle instead of leaking it", result.res.GetResourceID())
+	p.AddIdle(result.res)
+}
+
+// createRequest is one waiter's ask for a newly-created resource, coalesced
+// by runCreateCoordinator with any other requests arriving in the same
+// window so the factory sees a single batched Create call.
+type createRequest struct {
+	resultCh chan createResult
+}
+
+type createResult struct {
+	res types.NetworkResource
+	err error
+}
+
+// runCreateCoordinator deduplicates concurrent factory.Create calls: it
+// batches whichever requests are already queued on createReqCh with the one
+// that woke it up, and issues a single Create(M) call for the batch instead
+// of M parallel single-item calls. This avoids a pod-startup burst turning
+// into a thundering herd against the ECS OpenAPI's per-account rate limit,
+// without delaying a lone request that has nothing to batch with - it
+// drains only what's already queued, it never waits for more to arrive.
+func (p *simpleObjectPool) runCreateCoordinator() {
+	for req := range p.createReqCh {
+		pending := []*createRequest{req}
+	drain:
+		for {
+			select {
+			case r := <-p.createReqCh:
+				pending = append(pending, r)
+			default:
+				break drain
+			}
+		}
+
+		claimed := p.claimTokens(len(pending))
+		if claimed == 0 {
+			for _, r := range pending {
+				r.resultCh <- createResult{err: ErrNoAvailableResource}
+			}
+			continue
+		}
+
+		resList, err := p.factory.Create(claimed)
+		if err != nil {
+			log.Errorf("error create from factory: %v", err)
+		}
+
+		for i, r := range pending {
+			switch {
+			case i < claimed && i < len(resList):
+				r.resultCh <- createResult{res: resList[i]}
+			case i < claimed:
+				// token claimed but factory didn't produce enough resources; give it back
+				p.returnToken()
+				r.resultCh <- createResult{err: fmt.Errorf("error create from factory: %v", err)}
+			default:
+				r.resultCh <- createResult{err: ErrNoAvailableResource}
+			}
+		}
+	}
+}
+
+// claimTokens claims up to n tokens from tokenCh without blocking, returning
+// however many were actually available. It reads the channel pointer under
+// p.lock so it can't race with UpdateConfig swapping in a bigger channel;
+// the receives themselves happen on that local copy, outside the lock.
+func (p *simpleObjectPool) claimTokens(n int) int {
+	p.lock.Lock()
+	ch := p.tokenCh
+	p.lock.Unlock()
+
+	claimed := 0
+	for i := 0; i < n; i++ {
+		select {
+		case <-ch:
+			claimed++
+		default:
+			return claimed
+		}
+	}
+	return claimed
+}
+
+// Stats returns a snapshot of the pool's current state and cumulative
+// counters. Safe for concurrent use.
+func (p *simpleObjectPool) Stats() PoolStats {
+	p.lock.Lock()
+	idle := p.idle.Size()
+	inuse := len(p.inuse)
+	capacity := p.capacity
+	backoff := p.backoffTime
+	p.lock.Unlock()
+
+	return PoolStats{
+		Idle:     idle,
+		Inuse:    inuse,
+		Capacity: capacity,
+
+		Hits:     atomic.LoadUint64(&p.stats.hits),
+		Misses:   atomic.LoadUint64(&p.stats.misses),
+		Timeouts: atomic.LoadUint64(&p.stats.timeouts),
+
+		Creates:         atomic.LoadUint64(&p.stats.creates),
+		CreateFailures:  atomic.LoadUint64(&p.stats.createFailures),
+		Disposes:        atomic.LoadUint64(&p.stats.disposes),
+		DisposeFailures: atomic.LoadUint64(&p.stats.disposeFailures),
+		Releases:        atomic.LoadUint64(&p.stats.releases),
+
+		TotalAcquireWait: time.Duration(atomic.LoadInt64(&p.stats.totalAcquireWait)),
+		CurrentBackoff:   backoff,
+	}
+}
+
 func (p *simpleObjectPool) AcquireAny(ctx context.Context, idempotentKey string) (types.NetworkResource, error) {
 	return p.Acquire(ctx, "", idempotentKey)
 }
 
+// waitForRelease blocks a FIFO waiter w until ReleaseWithReverse hands it a
+// resource directly, p.poolTimeout elapses, or ctx is done. On timeout/ctx
+// the waiter is removed from the queue so it isn't handed a resource after
+// the caller has given up.
+func (p *simpleObjectPool) waitForRelease(ctx context.Context, resID, idempotentKey string, w chan *poolItem) (types.NetworkResource, error) {
+	var timeoutCh <-chan time.Time
+	if p.poolTimeout > 0 {
+		timer := time.NewTimer(p.poolTimeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	select {
+	case item := <-w:
+		p.lock.Lock()
+		p.addInuseLocked(item.res, idempotentKey, item.createdAt)
+		p.lock.Unlock()
+		log.Infof("acquire (expect %s): return handed-off %s", resID, item.res.GetResourceID())
+		atomic.AddUint64(&p.stats.hits, 1)
+		return item.res, nil
+	case <-timeoutCh:
+		p.removeWaiterLocked(w)
+		p.reclaimAbandonedHandoff(w, resID)
+		log.Infof("acquire (expect %s): return err %v", resID, ErrPoolTimeout)
+		atomic.AddUint64(&p.stats.timeouts, 1)
+		return nil, ErrPoolTimeout
+	case <-ctx.Done():
+		p.removeWaiterLocked(w)
+		p.reclaimAbandonedHandoff(w, resID)
+		log.Infof("acquire (expect %s): return err %v", resID, ErrContextDone)
+		atomic.AddUint64(&p.stats.timeouts, 1)
+		return nil, ErrContextDone
+	}
+}
+
+func (p *simpleObjectPool) removeWaiterLocked(w chan *poolItem) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for i, other := range p.waiters {
+		if other == w {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// reclaimAbandonedHandoff guards against the race between ReleaseWithReverse
+// handing a resource to w and this waiter giving up (timeout/ctx) at the
+// same instant: since the hand-off is a non-blocking send into a buffered
+// channel, it can succeed with nobody left to read it. Called after
+// removeWaiterLocked, which - by taking p.lock - can't return until any
+// ReleaseWithReverse call already in flight for this w has finished, so by
+// this point a hand-off has either already landed in w's buffer (drained
+// here and returned to idle) or can never happen (w was removed in time).
+func (p *simpleObjectPool) reclaimAbandonedHandoff(w chan *poolItem, resID string) {
+	select {
+	case item := <-w:
+		log.Infof("acquire (expect %s): reclaiming hand-off to abandoned waiter, returning %s to idle", resID, item.res.GetResourceID())
+		p.AddIdle(item.res)
+	default:
+	}
+}
+
+// addInuseLocked records res as inuse, preserving createdAt across idle/
+// waiter hand-offs so MaxLifetime reaping stays accurate. Callers must hold
+// p.lock.
+func (p *simpleObjectPool) addInuseLocked(res types.NetworkResource, idempotentKey string, createdAt time.Time) {
+	p.inuse[res.GetResourceID()] = poolItem{
+		res:           res,
+		idempotentKey: idempotentKey,
+		createdAt:     createdAt,
+		lastUsedAt:    time.Now(),
+	}
+	if p.stateStore != nil {
+		if err := p.stateStore.PersistInuse(res.GetResourceID(), idempotentKey); err != nil {
+			log.Warnf("error persisting inuse state for %s: %v", res.GetResourceID(), err)
+		}
+		if err := p.stateStore.DeleteIdle(res.GetResourceID()); err != nil {
+			log.Warnf("error deleting idle state for %s: %v", res.GetResourceID(), err)
+		}
+	}
+}
+
 func (p *simpleObjectPool) Stat(resID string) error {
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -377,11 +861,43 @@ func (p *simpleObjectPool) ReleaseWithReverse(resID string, reverse time.Duratio
 
 	log.Infof("release %s, reverse %v: return success", resID, reverse)
 	delete(p.inuse, resID)
+	atomic.AddUint64(&p.stats.releases, 1)
+
+	// Hand the resource straight to the oldest waiter, if any, instead of
+	// idling it - this is what lets Acquire wait out a capacity-bound pool
+	// instead of failing immediately.
+	for len(p.waiters) > 0 {
+		w := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		select {
+		case w <- &poolItem{res: res.res, createdAt: res.createdAt}:
+			if p.stateStore != nil {
+				if err := p.stateStore.DeleteInuse(resID); err != nil {
+					log.Warnf("error deleting inuse state for %s: %v", resID, err)
+				}
+			}
+			return nil
+		default:
+			// w's one-slot buffer is already full; try the next one. (A
+			// waiter that gave up but is still queued accepts the send;
+			// reclaimAbandonedHandoff returns that item to idle.)
+			continue
+		}
+	}
+
 	reverseTo := time.Now()
 	if reverse > 0 {
 		reverseTo = reverseTo.Add(reverse)
 	}
-	p.idle.Push(&poolItem{res: res.res, reverse: reverseTo})
+	p.idle.Push(&poolItem{res: res.res, reverse: reverseTo, createdAt: res.createdAt, lastUsedAt: time.Now()})
+	if p.stateStore != nil {
+		if err := p.stateStore.DeleteInuse(resID); err != nil {
+			log.Warnf("error deleting inuse state for %s: %v", resID, err)
+		}
+		if err := p.stateStore.PersistIdle(resID, reverseTo); err != nil {
+			log.Warnf("error persisting idle state for %s: %v", resID, err)
+		}
+	}
 	p.notify()
 	return nil
 }
@@ -390,16 +906,59 @@ func (p *simpleObjectPool) Release(resID string) error {
 }
 
 func (p *simpleObjectPool) AddIdle(resource types.NetworkResource) {
+	now := time.Now()
+	p.addIdleWithTimestamps(resource, now, now)
+}
+
+// addIdleWithTimestamps is AddIdle with caller-supplied createdAt/
+// lastUsedAt, so re-idling a resource (e.g. a stale item that failed
+// disposal in checkIdle) can preserve its original timestamps instead of
+// resetting the MaxLifetime/MaxIdleTime clocks it was reaped for.
+func (p *simpleObjectPool) addIdleWithTimestamps(resource types.NetworkResource, createdAt, lastUsedAt time.Time) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
-	p.idle.Push(&poolItem{res: resource, reverse: time.Now()})
+
+	resID := resource.GetResourceID()
+	if idempotentKey, ok := p.pendingInuse[resID]; ok {
+		// stateStore says this resource was inuse before the restart;
+		// replay it as inuse instead of idling it, before trusting what
+		// the Initializer derived from cloud metadata alone.
+		delete(p.pendingInuse, resID)
+		p.addInuseLocked(resource, idempotentKey, time.Now())
+		return
+	}
+
+	reverse := time.Now()
+	if reverseAt, ok := p.pendingIdle[resID]; ok {
+		// stateStore says this resource had a reverse reservation before the
+		// restart; replay it instead of making the resource immediately
+		// re-leasable.
+		delete(p.pendingIdle, resID)
+		reverse = reverseAt
+	}
+
+	p.idle.Push(&poolItem{res: resource, reverse: reverse, createdAt: createdAt, lastUsedAt: lastUsedAt})
+	if p.stateStore != nil {
+		if err := p.stateStore.PersistIdle(resID, reverse); err != nil {
+			log.Warnf("error persisting idle state for %s: %v", resID, err)
+		}
+	}
 }
 
 func (p *simpleObjectPool) AddInuse(res types.NetworkResource, idempotentKey string) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
+	delete(p.pendingInuse, res.GetResourceID())
+	now := time.Now()
 	p.inuse[res.GetResourceID()] = poolItem{
 		res:           res,
 		idempotentKey: idempotentKey,
+		createdAt:     now,
+		lastUsedAt:    now,
+	}
+	if p.stateStore != nil {
+		if err := p.stateStore.PersistInuse(res.GetResourceID(), idempotentKey); err != nil {
+			log.Warnf("error persisting inuse state for %s: %v", res.GetResourceID(), err)
+		}
 	}
 }
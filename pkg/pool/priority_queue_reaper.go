@@ -0,0 +1,33 @@
+package pool
+
+import "time"
+
+// expireStaleIdleLocked scans the full queue (not just the head) and pulls
+// out any item whose idle time exceeds maxIdleTime or whose age exceeds
+// maxLifetime, rebuilding the heap from the survivors. Callers must hold
+// the owning pool's lock. A zero duration disables the corresponding check.
+func (q *priorityQeueu) expireStaleIdleLocked(maxIdleTime, maxLifetime time.Duration) []*poolItem {
+	now := time.Now()
+
+	var expired, keep []*poolItem
+	for i := 0; i < q.size; i++ {
+		item := q.slots[i]
+		stale := (maxIdleTime > 0 && now.Sub(item.lastUsedAt) > maxIdleTime) ||
+			(maxLifetime > 0 && now.Sub(item.createdAt) > maxLifetime)
+		if stale {
+			expired = append(expired, item)
+		} else {
+			keep = append(keep, item)
+		}
+	}
+
+	if len(expired) == 0 {
+		return nil
+	}
+
+	q.size = 0
+	for _, item := range keep {
+		q.Push(item)
+	}
+	return expired
+}
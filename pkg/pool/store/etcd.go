@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultEtcdTimeout = 5 * time.Second
+
+// EtcdStore is an etcd/KV-backed pool.StateStore. Unlike LevelDBStore it is
+// shared across hosts, which lets multiple terway instances on the same
+// node (or during a rolling upgrade) coordinate over the same inventory
+// instead of each re-deriving it independently.
+type EtcdStore struct {
+	client *clientv3.Client
+	prefix string
+}
+
+// NewEtcdStore connects to the etcd cluster described by u, using u.Host as
+// the endpoint and u.Path as the key prefix, e.g. etcd://127.0.0.1:2379/terway/pool.
+func NewEtcdStore(u *url.URL) (*EtcdStore, error) {
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{u.Host},
+		DialTimeout: defaultEtcdTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to etcd at %s: %v", u.Host, err)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	if prefix == "" {
+		prefix = "terway/pool"
+	}
+	return &EtcdStore{client: cli, prefix: prefix}, nil
+}
+
+func (s *EtcdStore) inuseKey(resID string) string { return s.prefix + "/inuse/" + resID }
+func (s *EtcdStore) idleKey(resID string) string  { return s.prefix + "/idle/" + resID }
+
+// LoadInuse implements pool.StateStore.
+func (s *EtcdStore) LoadInuse() (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultEtcdTimeout)
+	defer cancel()
+
+	resp, err := s.client.Get(ctx, s.prefix+"/inuse/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("error loading inuse state from etcd: %v", err)
+	}
+
+	result := make(map[string]string, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		resID := strings.TrimPrefix(string(kv.Key), s.prefix+"/inuse/")
+		var rec inuseRecord
+		if err := json.Unmarshal(kv.Value, &rec); err != nil {
+			return nil, fmt.Errorf("error decoding inuse record for %s: %v", resID, err)
+		}
+		result[resID] = rec.IdempotentKey
+	}
+	return result, nil
+}
+
+// PersistInuse implements pool.StateStore.
+func (s *EtcdStore) PersistInuse(resID, idempotentKey string) error {
+	data, err := json.Marshal(inuseRecord{IdempotentKey: idempotentKey})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultEtcdTimeout)
+	defer cancel()
+	_, err = s.client.Put(ctx, s.inuseKey(resID), string(data))
+	return err
+}
+
+// DeleteInuse implements pool.StateStore.
+func (s *EtcdStore) DeleteInuse(resID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultEtcdTimeout)
+	defer cancel()
+	_, err := s.client.Delete(ctx, s.inuseKey(resID))
+	return err
+}
+
+// PersistIdle implements pool.StateStore.
+func (s *EtcdStore) PersistIdle(resID string, reverseAt time.Time) error {
+	data, err := json.Marshal(idleRecord{ReverseAt: reverseAt})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultEtcdTimeout)
+	defer cancel()
+	_, err = s.client.Put(ctx, s.idleKey(resID), string(data))
+	return err
+}
+
+// LoadIdle implements pool.StateStore.
+func (s *EtcdStore) LoadIdle() (map[string]time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultEtcdTimeout)
+	defer cancel()
+
+	resp, err := s.client.Get(ctx, s.prefix+"/idle/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("error loading idle state from etcd: %v", err)
+	}
+
+	result := make(map[string]time.Time, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		resID := strings.TrimPrefix(string(kv.Key), s.prefix+"/idle/")
+		var rec idleRecord
+		if err := json.Unmarshal(kv.Value, &rec); err != nil {
+			return nil, fmt.Errorf("error decoding idle record for %s: %v", resID, err)
+		}
+		result[resID] = rec.ReverseAt
+	}
+	return result, nil
+}
+
+// DeleteIdle implements pool.StateStore.
+func (s *EtcdStore) DeleteIdle(resID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultEtcdTimeout)
+	defer cancel()
+	_, err := s.client.Delete(ctx, s.idleKey(resID))
+	return err
+}
+
+// Close releases the underlying etcd client.
+func (s *EtcdStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const defaultLevelDBPath = "/var/lib/terway/pool.db"
+
+const (
+	inusePrefix = "inuse/"
+	idlePrefix  = "idle/"
+)
+
+type inuseRecord struct {
+	IdempotentKey string `json:"idempotentKey"`
+}
+
+type idleRecord struct {
+	ReverseAt time.Time `json:"reverseAt"`
+}
+
+// LevelDBStore is an embedded, file-backed pool.StateStore. It is the
+// default persistence layer: it survives a single terway daemon restart
+// without requiring any external service.
+type LevelDBStore struct {
+	db *leveldb.DB
+}
+
+// NewLevelDBStore opens (creating if absent) a leveldb database at path.
+func NewLevelDBStore(path string) (*LevelDBStore, error) {
+	if path == "" {
+		path = defaultLevelDBPath
+	}
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error opening leveldb state store at %s: %v", path, err)
+	}
+	return &LevelDBStore{db: db}, nil
+}
+
+// LoadInuse implements pool.StateStore.
+func (s *LevelDBStore) LoadInuse() (map[string]string, error) {
+	result := make(map[string]string)
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(inusePrefix)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		resID := string(iter.Key()[len(inusePrefix):])
+		var rec inuseRecord
+		if err := json.Unmarshal(iter.Value(), &rec); err != nil {
+			return nil, fmt.Errorf("error decoding inuse record for %s: %v", resID, err)
+		}
+		result[resID] = rec.IdempotentKey
+	}
+	return result, iter.Error()
+}
+
+// PersistInuse implements pool.StateStore.
+func (s *LevelDBStore) PersistInuse(resID, idempotentKey string) error {
+	data, err := json.Marshal(inuseRecord{IdempotentKey: idempotentKey})
+	if err != nil {
+		return err
+	}
+	return s.db.Put([]byte(inusePrefix+resID), data, nil)
+}
+
+// DeleteInuse implements pool.StateStore.
+func (s *LevelDBStore) DeleteInuse(resID string) error {
+	return s.db.Delete([]byte(inusePrefix+resID), nil)
+}
+
+// PersistIdle implements pool.StateStore.
+func (s *LevelDBStore) PersistIdle(resID string, reverseAt time.Time) error {
+	data, err := json.Marshal(idleRecord{ReverseAt: reverseAt})
+	if err != nil {
+		return err
+	}
+	return s.db.Put([]byte(idlePrefix+resID), data, nil)
+}
+
+// LoadIdle implements pool.StateStore.
+func (s *LevelDBStore) LoadIdle() (map[string]time.Time, error) {
+	result := make(map[string]time.Time)
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(idlePrefix)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		resID := string(iter.Key()[len(idlePrefix):])
+		var rec idleRecord
+		if err := json.Unmarshal(iter.Value(), &rec); err != nil {
+			return nil, fmt.Errorf("error decoding idle record for %s: %v", resID, err)
+		}
+		result[resID] = rec.ReverseAt
+	}
+	return result, iter.Error()
+}
+
+// DeleteIdle implements pool.StateStore.
+func (s *LevelDBStore) DeleteIdle(resID string) error {
+	return s.db.Delete([]byte(idlePrefix+resID), nil)
+}
+
+// Close releases the underlying leveldb handle.
+func (s *LevelDBStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,35 @@
+// Package store provides StateStore implementations for pool.ObjectPool
+// inventory persistence, selectable by URI scheme so a single daemon config
+// value chooses between embedded (leveldb) and HA (etcd) backends.
+package store
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/AliyunContainerService/terway/pkg/pool"
+)
+
+// New returns a pool.StateStore for the given URI. Supported schemes:
+//
+//	leveldb:///var/lib/terway/pool.db  - embedded, single-daemon crash recovery (default)
+//	etcd://host:2379/terway/pool       - shared KV store for HA deployments
+func New(uri string) (pool.StateStore, error) {
+	if uri == "" {
+		return NewLevelDBStore(defaultLevelDBPath)
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing state store uri %q: %v", uri, err)
+	}
+
+	switch u.Scheme {
+	case "leveldb", "":
+		return NewLevelDBStore(u.Path)
+	case "etcd":
+		return NewEtcdStore(u)
+	default:
+		return nil, fmt.Errorf("unsupported state store scheme %q", u.Scheme)
+	}
+}
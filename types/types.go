@@ -0,0 +1,36 @@
+// Package types holds the network resource types shared between the aliyun
+// ECS client and the pool package, so neither has to depend on the other.
+package types
+
+import "net"
+
+// NetworkResource is a cloud network resource (currently only ENI) that can
+// be tracked by pool.ObjectPool.
+type NetworkResource interface {
+	GetResourceID() string
+}
+
+// ENI describes an Elastic Network Interface and the addresses delegated to
+// it.
+type ENI struct {
+	ID          string
+	MAC         string
+	MaxIPs      int
+	VSwitchCIDR *net.IPNet
+	GatewayIP   net.IP
+
+	// Trunk is true when this ENI was created by AllocateTrunkENI and can
+	// carry VLAN-tagged branch ENIs.
+	Trunk bool
+	// TrunkID is the ID of the trunk ENI this branch ENI is attached to.
+	// Empty for a non-branch ENI.
+	TrunkID string
+	// VID is the VLAN tag this branch ENI was attached under. Zero for a
+	// non-branch ENI.
+	VID int
+}
+
+// GetResourceID implements NetworkResource.
+func (e *ENI) GetResourceID() string {
+	return e.ID
+}